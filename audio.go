@@ -0,0 +1,265 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gordonklaus/portaudio"
+	"layeh.com/gopus"
+)
+
+// captureDevice is one resolved, open input device being mixed into the
+// outgoing stream. For secondary devices, buf is continually overwritten
+// by a dedicated read loop and must be read under mu; the primary
+// device's buf is its stream's own buffer, filled and read by the
+// encoding loop itself.
+type captureDevice struct {
+	stream *portaudio.Stream
+	buf    []int16
+	mu     sync.Mutex
+}
+
+type Audio struct {
+	stream    *portaudio.Stream
+	captures  []*captureDevice
+	outStream *portaudio.Stream
+}
+
+func (audio *Audio) Start() error {
+	return portaudio.Initialize()
+}
+
+func (audio *Audio) Stop() error {
+	return portaudio.Terminate()
+}
+
+// silenceFrame is the Opus frame Discord expects when a speaker falls
+// silent, so clients flush their jitter buffers instead of interpolating.
+var silenceFrame = []byte{0xF8, 0xFF, 0xFE}
+
+// silenceFrameCount is how many times silenceFrame is sent on the
+// speaking->silent falling edge, per the discordgo airhorn example.
+const silenceFrameCount = 5
+
+func (audio *Audio) Open(discord *Discord, audioConfig AudioConfig, vad VadConfig, errCh chan<- error) error {
+	inputs := audioConfig.resolveInputs()
+	primaryBuf := make([]int16, 960*2)
+	primaryStream, deviceName, err := openCapture(inputs[0], primaryBuf)
+	if err != nil {
+		return err
+	}
+	if err := primaryStream.Start(); err != nil {
+		primaryStream.Close()
+		return err
+	}
+	audio.stream = primaryStream
+	discord.SetDevice(deviceName)
+	primary := &captureDevice{stream: primaryStream, buf: primaryBuf}
+	audio.captures = append(audio.captures, primary)
+
+	for _, input := range inputs[1:] {
+		capture := &captureDevice{buf: make([]int16, 960*2)}
+		local := make([]int16, 960*2)
+		stream, _, err := openCapture(input, local)
+		if err != nil {
+			return err
+		}
+		if err := stream.Start(); err != nil {
+			stream.Close()
+			return err
+		}
+		capture.stream = stream
+		audio.captures = append(audio.captures, capture)
+
+		go func(capture *captureDevice, local []int16) {
+			for {
+				err := capture.stream.Read()
+				if err != nil {
+					errCh <- err
+					if err == portaudio.InputOverflowed {
+						continue
+					}
+					return
+				}
+				capture.mu.Lock()
+				copy(capture.buf, local)
+				capture.mu.Unlock()
+			}
+		}(capture, local)
+	}
+
+	secondary := audio.captures[1:]
+	mixed := make([]int16, 960*2)
+	go func() {
+		opusEncoder, err := gopus.NewEncoder(48000, 2, gopus.Audio)
+		if err != nil {
+			errCh <- err
+			return
+		}
+		detector := vad.NewDetector()
+		speaking := false
+		for {
+			if err := primary.stream.Read(); err != nil {
+				errCh <- err
+				if err == portaudio.InputOverflowed {
+					continue
+				}
+				return
+			}
+			copy(mixed, primaryBuf)
+			for _, capture := range secondary {
+				capture.mu.Lock()
+				mixInto(mixed, capture.buf)
+				capture.mu.Unlock()
+			}
+
+			nowSpeaking := detector.Detect(mixed)
+			if nowSpeaking && !speaking {
+				speaking = true
+				go discord.Speaking(true)
+			} else if !nowSpeaking && speaking {
+				speaking = false
+				go discord.Speaking(false)
+				for i := 0; i < silenceFrameCount; i++ {
+					discord.SendVoice(silenceFrame)
+				}
+			}
+			if !speaking {
+				continue
+			}
+			opus, err := opusEncoder.Encode(mixed, 960, 960*2*2)
+			if err != nil {
+				errCh <- err
+				return
+			}
+			discord.SendVoice(opus)
+		}
+	}()
+	return nil
+}
+
+// OpenReceive opens a PortAudio output stream and plays back everything
+// discord sends us on its OpusRecv channel, decoding and mixing per-SSRC
+// so multiple speakers are heard at once.
+func (audio *Audio) OpenReceive(discord *Discord, errCh chan<- error) error {
+	out := make([]int16, 960*2)
+	stream, err := portaudio.OpenDefaultStream(0, 2, 48000, len(out), out)
+	if err != nil {
+		return err
+	}
+	if err := stream.Start(); err != nil {
+		stream.Close()
+		return err
+	}
+	audio.outStream = stream
+
+	decoders := map[uint32]*gopus.Decoder{}
+	var mu sync.Mutex
+
+	// The voice connection can be torn down and re-created by /leave and
+	// /join, which hands out a fresh OpusRecv channel each time, so this
+	// re-fetches it instead of reading the one live at startup.
+	go func() {
+		for {
+			recv := discord.OpusRecv()
+			if recv == nil {
+				time.Sleep(200 * time.Millisecond)
+				continue
+			}
+			for packet := range recv {
+				mu.Lock()
+				decoder, ok := decoders[packet.SSRC]
+				if !ok {
+					decoder, err = gopus.NewDecoder(48000, 2)
+					if err != nil {
+						mu.Unlock()
+						errCh <- err
+						continue
+					}
+					decoders[packet.SSRC] = decoder
+				}
+				mu.Unlock()
+
+				pcm, err := decoder.Decode(packet.Opus, 960, false)
+				if err != nil {
+					errCh <- err
+					continue
+				}
+
+				mu.Lock()
+				mixInto(out, pcm)
+				mu.Unlock()
+			}
+		}
+	}()
+
+	go func() {
+		for {
+			mu.Lock()
+			err := stream.Write()
+			if err != nil {
+				mu.Unlock()
+				errCh <- err
+				return
+			}
+			for i := range out {
+				out[i] = 0
+			}
+			mu.Unlock()
+		}
+	}()
+
+	return nil
+}
+
+// mixInto saturating-adds pcm into dst, treating both as interleaved
+// stereo int16 samples.
+func mixInto(dst []int16, pcm []int16) {
+	n := len(dst)
+	if len(pcm) < n {
+		n = len(pcm)
+	}
+	for i := 0; i < n; i++ {
+		sum := int32(dst[i]) + int32(pcm[i])
+		if sum > 32767 {
+			sum = 32767
+		} else if sum < -32768 {
+			sum = -32768
+		}
+		dst[i] = int16(sum)
+	}
+}
+
+func (audio *Audio) Close() error {
+	for _, capture := range audio.captures {
+		if capture.stream == audio.stream {
+			continue
+		}
+		if err := capture.stream.Stop(); err != nil {
+			capture.stream.Close()
+			return err
+		}
+		if err := capture.stream.Close(); err != nil {
+			return err
+		}
+	}
+	if audio.stream != nil {
+		if err := audio.stream.Stop(); err != nil {
+			audio.stream.Close()
+			return err
+		}
+		if err := audio.stream.Close(); err != nil {
+			return err
+		}
+	}
+	if audio.outStream != nil {
+		if err := audio.outStream.Stop(); err != nil {
+			audio.outStream.Close()
+			return err
+		}
+		if err := audio.outStream.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
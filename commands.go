@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// slashCommands are the application commands registered on Start, letting
+// the bot be controlled from inside Discord instead of via config.yml.
+var slashCommands = []*discordgo.ApplicationCommand{
+	{
+		Name:        "join",
+		Description: "Join a voice channel",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionChannel,
+				Name:        "channel",
+				Description: "Voice channel to join",
+				Required:    true,
+			},
+		},
+	},
+	{Name: "leave", Description: "Leave the current voice channel"},
+	{Name: "mute", Description: "Mute outgoing audio"},
+	{Name: "unmute", Description: "Unmute outgoing audio"},
+	{Name: "status", Description: "Show device, speaking state and packet counters"},
+}
+
+// registerCommands upserts slashCommands as guild commands, which apply
+// immediately (global commands can take up to an hour to propagate).
+func (discord *Discord) registerCommands(applicationId, guildId string) error {
+	for _, command := range slashCommands {
+		if _, err := discord.session.ApplicationCommandCreate(applicationId, guildId, command); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (discord *Discord) handleInteraction(session *discordgo.Session, interaction *discordgo.InteractionCreate) {
+	if interaction.Type != discordgo.InteractionApplicationCommand {
+		return
+	}
+
+	data := interaction.ApplicationCommandData()
+	switch data.Name {
+	case "join":
+		channel := data.Options[0].ChannelValue(session)
+		if err := discord.JoinVoiceChannel(interaction.GuildID, channel.ID); err != nil {
+			respond(session, interaction, "Failed to join: "+err.Error())
+			return
+		}
+		respond(session, interaction, "Joined "+channel.Name+".")
+
+	case "leave":
+		if err := discord.LeaveVoiceChannel(); err != nil {
+			respond(session, interaction, "Failed to leave: "+err.Error())
+			return
+		}
+		respond(session, interaction, "Left the voice channel.")
+
+	case "mute":
+		discord.SetMuted(true)
+		respond(session, interaction, "Muted.")
+
+	case "unmute":
+		discord.SetMuted(false)
+		respond(session, interaction, "Unmuted.")
+
+	case "status":
+		stats := discord.Stats()
+		respond(session, interaction, fmt.Sprintf(
+			"Device: %s\nMuted: %v\nSpeaking: %v\nPackets sent: %d\nPackets dropped: %d",
+			stats.Device, stats.Muted, stats.Speaking, stats.PacketsSent, stats.PacketsDropped,
+		))
+	}
+}
+
+func respond(session *discordgo.Session, interaction *discordgo.InteractionCreate, content string) {
+	session.InteractionRespond(interaction.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{Content: content},
+	})
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gordonklaus/portaudio"
+	"github.com/sirupsen/logrus"
+)
+
+// AudioInputConfig selects a single PortAudio input device.
+type AudioInputConfig struct {
+	// HostApi is matched case-insensitively against the host API name,
+	// e.g. "WASAPI", "MME", "DirectSound", "WDM-KS". Empty uses the
+	// system default host API.
+	HostApi string `yaml:"host_api"`
+	// InputDevice is matched by exact index or by case-insensitive
+	// substring against the device name. Empty uses that host API's
+	// default input device, or its first input-capable device when it has
+	// no default or when Loopback is set.
+	InputDevice string `yaml:"input_device"`
+	// Loopback restricts the search to WASAPI render-loopback devices,
+	// so system audio can be captured instead of a microphone.
+	Loopback bool `yaml:"loopback"`
+}
+
+// AudioConfig configures the input device(s) vgo2discord captures from.
+// HostApi/InputDevice/Loopback describe a single device; Inputs lets
+// several devices be summed into one stereo stream (e.g. mic + system
+// audio) and takes precedence when non-empty.
+type AudioConfig struct {
+	HostApi     string             `yaml:"host_api"`
+	InputDevice string             `yaml:"input_device"`
+	Loopback    bool               `yaml:"loopback"`
+	Inputs      []AudioInputConfig `yaml:"inputs"`
+}
+
+func (config AudioConfig) resolveInputs() []AudioInputConfig {
+	if len(config.Inputs) > 0 {
+		return config.Inputs
+	}
+	return []AudioInputConfig{{
+		HostApi:     config.HostApi,
+		InputDevice: config.InputDevice,
+		Loopback:    config.Loopback,
+	}}
+}
+
+// findDevice resolves an AudioInputConfig to a concrete PortAudio device,
+// enumerating host APIs with portaudio.HostApis() and searching their
+// devices for one that matches.
+func findDevice(input AudioInputConfig) (*portaudio.DeviceInfo, error) {
+	hostApis, err := portaudio.HostApis()
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []*portaudio.HostApiInfo
+	if input.HostApi == "" {
+		defaultHostApi, err := portaudio.DefaultHostApi()
+		if err != nil {
+			return nil, err
+		}
+		candidates = []*portaudio.HostApiInfo{defaultHostApi}
+	} else {
+		for _, api := range hostApis {
+			if strings.Contains(strings.ToLower(api.Name), strings.ToLower(input.HostApi)) {
+				candidates = append(candidates, api)
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("vgo2discord: no host API matching %q", input.HostApi)
+	}
+
+	wantIndex, byIndex := -1, false
+	if input.InputDevice != "" {
+		if idx, err := strconv.Atoi(input.InputDevice); err == nil {
+			wantIndex, byIndex = idx, true
+		}
+	}
+
+	for _, api := range candidates {
+		if input.InputDevice == "" && !input.Loopback && api.DefaultInputDevice != nil {
+			return api.DefaultInputDevice, nil
+		}
+		for _, device := range api.Devices {
+			if device.MaxInputChannels == 0 {
+				continue
+			}
+			if input.Loopback && !strings.Contains(strings.ToLower(device.Name), "loopback") {
+				continue
+			}
+			switch {
+			case input.InputDevice == "":
+				return device, nil
+			case byIndex:
+				if device.Index == wantIndex {
+					return device, nil
+				}
+			case strings.Contains(strings.ToLower(device.Name), strings.ToLower(input.InputDevice)):
+				return device, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("vgo2discord: no input device matching %q on host API %q", input.InputDevice, input.HostApi)
+}
+
+// openCapture resolves input to a device and opens a 2ch/48kHz capture
+// stream on it, logging and returning the resolved device name.
+func openCapture(input AudioInputConfig, buf []int16) (*portaudio.Stream, string, error) {
+	device, err := findDevice(input)
+	if err != nil {
+		return nil, "", err
+	}
+	logrus.Infof("vgo2discord: capturing from %q (host API %s)", device.Name, device.HostApi.Name)
+
+	params := portaudio.StreamParameters{
+		Input: portaudio.StreamDeviceParameters{
+			Device:   device,
+			Channels: 2,
+			Latency:  device.DefaultLowInputLatency,
+		},
+		SampleRate:      48000,
+		FramesPerBuffer: len(buf) / 2,
+	}
+	stream, err := portaudio.OpenStream(params, buf)
+	return stream, device.Name, err
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// DiscordStats is a snapshot of the running voice connection, surfaced by
+// the /status slash command.
+type DiscordStats struct {
+	Device         string
+	PacketsSent    int
+	PacketsDropped int
+	Speaking       bool
+	Muted          bool
+}
+
+type Discord struct {
+	session *discordgo.Session
+	voice   *discordgo.VoiceConnection
+
+	mu       sync.Mutex
+	muted    bool
+	device   string
+	sent     int
+	dropped  int
+	speaking bool
+}
+
+func (discord *Discord) Start(token, guildId string) error {
+	session, err := discordgo.New("Bot " + token)
+	if err != nil {
+		return err
+	}
+	session.AddHandler(discord.handleInteraction)
+
+	if err := session.Open(); err != nil {
+		session.Close()
+		return err
+	}
+	discord.session = session
+
+	// ApplicationCommandCreate needs the application ID; State.User isn't
+	// guaranteed populated the instant Open() returns, so fetch it over
+	// REST instead of racing the gateway's READY event.
+	app, err := session.Application("@me")
+	if err != nil {
+		session.Close()
+		return err
+	}
+
+	if err := discord.registerCommands(app.ID, guildId); err != nil {
+		session.Close()
+		return err
+	}
+	return nil
+}
+
+func (discord *Discord) Stop() error {
+	return discord.session.Close()
+}
+
+// getVoice returns the current voice connection, if any. voice is
+// replaced by /join and /leave (and the capture/playback goroutines read
+// it continuously), so all access goes through discord.mu.
+func (discord *Discord) getVoice() *discordgo.VoiceConnection {
+	discord.mu.Lock()
+	defer discord.mu.Unlock()
+	return discord.voice
+}
+
+func (discord *Discord) JoinVoiceChannel(groupId, channelId string) error {
+	voice, err := discord.session.ChannelVoiceJoin(groupId, channelId, false, true)
+	if err != nil {
+		return err
+	}
+	discord.mu.Lock()
+	discord.voice = voice
+	discord.mu.Unlock()
+	return nil
+}
+
+func (discord *Discord) LeaveVoiceChannel() error {
+	discord.mu.Lock()
+	voice := discord.voice
+	discord.voice = nil
+	discord.mu.Unlock()
+	if voice == nil {
+		return nil
+	}
+	return voice.Disconnect()
+}
+
+func (discord *Discord) SendVoice(opus []byte) bool {
+	voice := discord.getVoice()
+	if voice == nil {
+		discord.mu.Lock()
+		discord.dropped++
+		discord.mu.Unlock()
+		return false
+	}
+	if voice.Ready == false || voice.OpusSend == nil {
+		discord.mu.Lock()
+		discord.dropped++
+		discord.mu.Unlock()
+		return false
+	}
+	if discord.Muted() {
+		opus = silenceFrame
+	}
+	voice.OpusSend <- opus
+	discord.mu.Lock()
+	discord.sent++
+	discord.mu.Unlock()
+	return true
+}
+
+func (discord *Discord) Speaking(speaking bool) bool {
+	voice := discord.getVoice()
+	discord.mu.Lock()
+	discord.speaking = speaking
+	discord.mu.Unlock()
+	if voice == nil {
+		return false
+	}
+	if voice.Ready == false {
+		return false
+	}
+	voice.Speaking(speaking)
+	return true
+}
+
+// OpusRecv returns the channel discordgo delivers incoming voice packets
+// on, so callers can decode and play back what other users are speaking.
+func (discord *Discord) OpusRecv() <-chan *discordgo.Packet {
+	voice := discord.getVoice()
+	if voice == nil {
+		return nil
+	}
+	return voice.OpusRecv
+}
+
+// SetMuted makes SendVoice substitute the silence frame instead of
+// forwarding real audio, without tearing down the voice connection.
+func (discord *Discord) SetMuted(muted bool) {
+	discord.mu.Lock()
+	defer discord.mu.Unlock()
+	discord.muted = muted
+}
+
+func (discord *Discord) Muted() bool {
+	discord.mu.Lock()
+	defer discord.mu.Unlock()
+	return discord.muted
+}
+
+// SetDevice records the resolved capture device name for display in
+// /status; it has no effect on playback.
+func (discord *Discord) SetDevice(device string) {
+	discord.mu.Lock()
+	defer discord.mu.Unlock()
+	discord.device = device
+}
+
+func (discord *Discord) Stats() DiscordStats {
+	discord.mu.Lock()
+	defer discord.mu.Unlock()
+	return DiscordStats{
+		Device:         discord.device,
+		PacketsSent:    discord.sent,
+		PacketsDropped: discord.dropped,
+		Speaking:       discord.speaking,
+		Muted:          discord.muted,
+	}
+}
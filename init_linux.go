@@ -0,0 +1,12 @@
+package main
+
+import (
+	"syscall"
+)
+
+func InitProcess() error {
+	if err := syscall.Setpriority(syscall.PRIO_PROCESS, 0, -5); err != nil && err != syscall.EPERM {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,9 @@
+package main
+
+import (
+	"golang.org/x/sys/windows"
+)
+
+func InitProcess() error {
+	return windows.SetPriorityClass(windows.CurrentProcess(), 0x00000080)
+}
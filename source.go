@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+
+	"layeh.com/gopus"
+)
+
+// frameInterval is the pacing of one 20ms Opus frame at 48kHz.
+const frameInterval = 20 * time.Millisecond
+
+// SourceConfig selects where vgo2discord's outgoing audio comes from.
+// Mode "" or "portaudio" captures from a local input device via Audio;
+// "dca", "ogg" and "ffmpeg" instead play back pre-recorded or streamed
+// media through Path (or Url for ffmpeg, which also accepts network
+// inputs).
+type SourceConfig struct {
+	Mode string `yaml:"source"`
+	Path string `yaml:"path"`
+	Url  string `yaml:"url"`
+}
+
+// Source plays pre-encoded or transcoded media into a voice channel,
+// as an alternative to Audio's live device capture.
+type Source struct{}
+
+// Play starts the configured source in the background and returns once
+// it's running; errors while playing are reported on errCh.
+func (source *Source) Play(config SourceConfig, discord *Discord, errCh chan<- error) error {
+	switch config.Mode {
+	case "", "portaudio":
+		return nil
+	case "dca":
+		return playDCA(config.Path, discord, errCh)
+	case "ogg":
+		return playOgg(config.Path, discord, errCh)
+	case "ffmpeg":
+		return playFFmpeg(config, discord, errCh)
+	default:
+		return fmt.Errorf("vgo2discord: unknown source %q", config.Mode)
+	}
+}
+
+// playDCA streams the DCA format used by the discordgo airhorn example:
+// a little-endian int16 length prefix followed by that many Opus bytes,
+// looping over the file once it reaches EOF.
+func playDCA(path string, discord *Discord, errCh chan<- error) error {
+	go func() {
+		ticker := time.NewTicker(frameInterval)
+		defer ticker.Stop()
+		for {
+			if err := playDCAOnce(path, discord, ticker); err != nil {
+				errCh <- err
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func playDCAOnce(path string, discord *Discord, ticker *time.Ticker) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	discord.Speaking(true)
+	defer discord.Speaking(false)
+	for {
+		var length int16
+		if err := binary.Read(reader, binary.LittleEndian, &length); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		opus := make([]byte, length)
+		if _, err := io.ReadFull(reader, opus); err != nil {
+			return err
+		}
+		<-ticker.C
+		discord.SendVoice(opus)
+	}
+}
+
+// playOgg streams an Ogg/Opus file, stripping the two mandatory header
+// packets (OpusHead and OpusTags) and forwarding every packet after that
+// as one 20ms Opus frame. It loops once the file is exhausted.
+func playOgg(path string, discord *Discord, errCh chan<- error) error {
+	go func() {
+		ticker := time.NewTicker(frameInterval)
+		defer ticker.Stop()
+		for {
+			if err := playOggOnce(path, discord, ticker); err != nil {
+				errCh <- err
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func playOggOnce(path string, discord *Discord, ticker *time.Ticker) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	packets, err := readOggPackets(f)
+	if err != nil {
+		return err
+	}
+	discord.Speaking(true)
+	defer discord.Speaking(false)
+	for i, packet := range packets {
+		if i < 2 {
+			// OpusHead, OpusTags
+			continue
+		}
+		<-ticker.C
+		discord.SendVoice(packet)
+	}
+	return nil
+}
+
+// readOggPackets demuxes an Ogg bitstream into its constituent packets,
+// reassembling packets that were split across page segments per RFC 3533.
+func readOggPackets(r io.Reader) ([][]byte, error) {
+	br := bufio.NewReader(r)
+	var packets [][]byte
+	var current []byte
+
+	for {
+		header := make([]byte, 27)
+		if _, err := io.ReadFull(br, header); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return packets, nil
+			}
+			return nil, err
+		}
+		if string(header[0:4]) != "OggS" {
+			return nil, fmt.Errorf("vgo2discord: not an Ogg page")
+		}
+
+		segCount := int(header[26])
+		segTable := make([]byte, segCount)
+		if _, err := io.ReadFull(br, segTable); err != nil {
+			return nil, err
+		}
+		for _, segLen := range segTable {
+			segment := make([]byte, segLen)
+			if _, err := io.ReadFull(br, segment); err != nil {
+				return nil, err
+			}
+			current = append(current, segment...)
+			if segLen < 255 {
+				packets = append(packets, current)
+				current = nil
+			}
+		}
+	}
+}
+
+// playFFmpeg spawns ffmpeg to decode arbitrary media (file path or URL)
+// to raw s16le/48kHz/stereo PCM, re-encodes it with gopus and paces
+// delivery at one 20ms frame per tick.
+func playFFmpeg(config SourceConfig, discord *Discord, errCh chan<- error) error {
+	input := config.Path
+	if input == "" {
+		input = config.Url
+	}
+
+	cmd := exec.Command("ffmpeg", "-i", input, "-f", "s16le", "-ar", "48000", "-ac", "2", "-")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	go func() {
+		defer cmd.Wait()
+
+		opusEncoder, err := gopus.NewEncoder(48000, 2, gopus.Audio)
+		if err != nil {
+			errCh <- err
+			return
+		}
+
+		ticker := time.NewTicker(frameInterval)
+		defer ticker.Stop()
+
+		discord.Speaking(true)
+		defer discord.Speaking(false)
+
+		pcm := make([]int16, 960*2)
+		raw := make([]byte, len(pcm)*2)
+		for {
+			if _, err := io.ReadFull(stdout, raw); err != nil {
+				if err != io.EOF && err != io.ErrUnexpectedEOF {
+					errCh <- err
+				}
+				return
+			}
+			for i := range pcm {
+				pcm[i] = int16(binary.LittleEndian.Uint16(raw[i*2:]))
+			}
+			opus, err := opusEncoder.Encode(pcm, 960, 960*2*2)
+			if err != nil {
+				errCh <- err
+				return
+			}
+			<-ticker.C
+			discord.SendVoice(opus)
+		}
+	}()
+	return nil
+}
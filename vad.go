@@ -0,0 +1,68 @@
+package main
+
+// VadConfig controls the voice-activity detector used to decide when the
+// captured input counts as speech versus silence.
+type VadConfig struct {
+	// Threshold is the peak sample amplitude (0-32767) above which a
+	// frame is considered speech.
+	Threshold int16 `yaml:"threshold"`
+	// Hysteresis is how many consecutive frames of the opposite state
+	// are required before flipping speaking/silent, to avoid chattering
+	// on brief dips or spikes.
+	Hysteresis int `yaml:"hysteresis"`
+}
+
+// defaultVadConfig is used when the user hasn't set vad.threshold in
+// config.yml, to keep behavior close to "any non-trivial sound".
+var defaultVadConfig = VadConfig{
+	Threshold:  500,
+	Hysteresis: 3,
+}
+
+// detector holds the running state of the voice-activity detector across
+// frames.
+type detector struct {
+	config   VadConfig
+	run      int
+	speaking bool
+}
+
+// NewDetector returns a detector seeded with this config, defaulting an
+// unset threshold to defaultVadConfig.
+func (config VadConfig) NewDetector() *detector {
+	if config.Threshold == 0 {
+		config.Threshold = defaultVadConfig.Threshold
+	}
+	if config.Hysteresis == 0 {
+		config.Hysteresis = defaultVadConfig.Hysteresis
+	}
+	return &detector{config: config}
+}
+
+// Detect reports whether frame counts as speech, applying hysteresis so a
+// single loud or quiet frame can't flip the state on its own.
+func (d *detector) Detect(frame []int16) bool {
+	var peak int32
+	for _, value := range frame {
+		abs := int32(value)
+		if abs < 0 {
+			abs = -abs
+		}
+		if abs > peak {
+			peak = abs
+		}
+	}
+
+	loud := peak > int32(d.config.Threshold)
+	if loud == d.speaking {
+		d.run = 0
+		return d.speaking
+	}
+
+	d.run++
+	if d.run >= d.config.Hysteresis {
+		d.speaking = loud
+		d.run = 0
+	}
+	return d.speaking
+}